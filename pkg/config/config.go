@@ -0,0 +1,39 @@
+package config
+
+// EmulatorMeta describes a single emulated system: where its libretro
+// core lives and how its video/audio output should be interpreted.
+type EmulatorMeta struct {
+	// Path is the libretro core file name relative to the cores directory.
+	Path string
+	// Width and Height are the system's native framebuffer dimensions.
+	Width  int
+	Height int
+	// Ratio is the display aspect ratio, e.g. 4.0/3.0.
+	Ratio float64
+	// AudioSampleRate is the output sample rate expected by the core.
+	AudioSampleRate int
+	// Fps is the nominal frame rate the core runs at.
+	Fps float64
+}
+
+// EmulatorConfig maps a system name (e.g. "gba", "nes") to its metadata.
+// It is compiled in and used as the fallback default for any system that
+// has no table of its own in systems.toml (see nanoarch.LoadProfiles).
+var EmulatorConfig = map[string]EmulatorMeta{
+	"gba": {
+		Path:            "gba_libretro",
+		Width:           240,
+		Height:          160,
+		Ratio:           1.5,
+		AudioSampleRate: 32768,
+		Fps:             60,
+	},
+	"nes": {
+		Path:            "nes_libretro",
+		Width:           256,
+		Height:          240,
+		Ratio:           256.0 / 240.0,
+		AudioSampleRate: 44100,
+		Fps:             60,
+	},
+}
@@ -0,0 +1,241 @@
+//go:build comparison
+// +build comparison
+
+package nanoarch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+)
+
+// Divergence records one tick where golden and candidate disagreed by more
+// than the configured thresholds.
+type Divergence struct {
+	Frame int
+	// PSNR is the peak signal-to-noise ratio between the two frames, in
+	// dB; +Inf if they are pixel-identical.
+	PSNR float64
+	// DiffPixels is the number of pixels whose RGBA differed at all.
+	DiffPixels int
+
+	GoldenFrame    GameFrame
+	CandidateFrame GameFrame
+	GoldenAudio    []int16
+	CandidateAudio []int16
+	GoldenState    []byte
+	CandidateState []byte
+}
+
+// ComparisonRunner replays the same input stream through two naEmulator
+// instances loaded with different core builds and reports the ticks where
+// their output diverges, porting the comparison harness from Gopher2600.
+//
+// nanoarch drives only one core per process (see NAEmulator), so golden
+// and candidate are not actually stepped concurrently: Run alternates the
+// package-global NAEmulator and outputImg between the two instances,
+// capturing one frame of each per tick, which is equivalent for a
+// deterministic core as long as both see the same input at the same
+// frame. Tee the live InputEvent source to both instances before calling
+// Run, or drive them from a recording made with StartRecording so both
+// replay byte-identical input.
+type ComparisonRunner struct {
+	golden    *naEmulator
+	candidate *naEmulator
+
+	// PixelThreshold is the number of differing pixels tolerated before a
+	// tick is reported as a Divergence.
+	PixelThreshold int
+	// PSNRThreshold is the minimum acceptable PSNR in dB; a tick scoring
+	// below it is reported as a Divergence. Zero disables the PSNR check.
+	PSNRThreshold float64
+
+	// DumpDir is where diverging frames (as PNG) and save states are
+	// written. Empty disables dumping.
+	DumpDir string
+}
+
+// NewComparisonRunner builds a ComparisonRunner comparing golden against
+// candidate. Both must already have a core and ROM loaded via coreLoad/
+// coreLoadGame.
+func NewComparisonRunner(golden, candidate *naEmulator) *ComparisonRunner {
+	return &ComparisonRunner{golden: golden, candidate: candidate}
+}
+
+// Tee forwards every event read from src onto both the golden and
+// candidate emulators' input channels until src is closed or ctx is
+// cancelled, so a single live InputEvent stream drives both instances
+// identically.
+func (cr *ComparisonRunner) Tee(ctx context.Context, src <-chan InputEvent) {
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case e, ok := <-src:
+				if !ok {
+					return
+				}
+				cr.golden.inputChannel <- e
+				cr.candidate.inputChannel <- e
+			}
+		}
+	}()
+}
+
+// Run advances both emulators by ticks frames, diffing their framebuffer
+// and pending audio batch after every tick, and returns one Divergence per
+// tick that exceeded PixelThreshold or fell below PSNRThreshold.
+func (cr *ComparisonRunner) Run(ctx context.Context, ticks int) ([]Divergence, error) {
+	if cr.golden == nil || cr.candidate == nil {
+		return nil, errors.New("nanoarch: comparison runner needs a golden and a candidate emulator")
+	}
+
+	var divergences []Divergence
+
+	for i := 0; i < ticks; i++ {
+		select {
+		case <-ctx.Done():
+			return divergences, ctx.Err()
+		default:
+		}
+
+		goldenFrame, goldenAudio, goldenState, err := cr.step(cr.golden)
+		if err != nil {
+			return divergences, err
+		}
+		candidateFrame, candidateAudio, candidateState, err := cr.step(cr.candidate)
+		if err != nil {
+			return divergences, err
+		}
+
+		diffPixels, psnr := diffFrames(goldenFrame.Image, candidateFrame.Image)
+		if diffPixels > cr.PixelThreshold || (cr.PSNRThreshold > 0 && psnr < cr.PSNRThreshold) {
+			d := Divergence{
+				Frame:          cr.golden.frame,
+				PSNR:           psnr,
+				DiffPixels:     diffPixels,
+				GoldenFrame:    goldenFrame,
+				CandidateFrame: candidateFrame,
+				GoldenAudio:    goldenAudio,
+				CandidateAudio: candidateAudio,
+				GoldenState:    goldenState,
+				CandidateState: candidateState,
+			}
+			if cr.DumpDir != "" {
+				if err := cr.dump(d); err != nil {
+					return divergences, err
+				}
+			}
+			divergences = append(divergences, d)
+		}
+	}
+
+	return divergences, nil
+}
+
+// step points the package globals at na, runs one frame, and snapshots the
+// resulting framebuffer, pending audio and core state before the next
+// instance takes its turn.
+func (cr *ComparisonRunner) step(na *naEmulator) (GameFrame, []int16, []byte, error) {
+	NAEmulator = na
+	outputImg = image.NewRGBA(image.Rect(0, 0, na.meta.Width, na.meta.Height))
+
+	nanoarchRun()
+
+	// Timestamp is derived from the emulator's own frame counter rather
+	// than wall clock time, so two runs of the same input stay comparable.
+	frame := GameFrame{Image: outputImg, Timestamp: int64(na.frame)}
+	audio := drainAudio(na)
+
+	state, err := getState()
+	if err != nil {
+		return frame, audio, nil, err
+	}
+	return frame, audio, state, nil
+}
+
+// drainAudio non-blockingly collects whatever samples the core pushed to
+// na's audio channel during the last step.
+func drainAudio(na *naEmulator) []int16 {
+	var out []int16
+	for {
+		select {
+		case batch := <-na.audioChannel:
+			out = append(out, batch...)
+		default:
+			return out
+		}
+	}
+}
+
+// diffFrames returns the number of pixels that differ at all between a and
+// b, plus the PSNR between them in dB (+Inf if they are identical).
+// Differently-sized frames are reported as fully diverged.
+func diffFrames(a, b *image.RGBA) (int, float64) {
+	if a == nil || b == nil || a.Bounds() != b.Bounds() {
+		return math.MaxInt32, 0
+	}
+
+	var diffPixels int
+	var squaredErrorSum float64
+	bounds := a.Bounds()
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			ar, ag, ab, aa := a.At(x, y).RGBA()
+			br, bg, bb, ba := b.At(x, y).RGBA()
+			if ar != br || ag != bg || ab != bb || aa != ba {
+				diffPixels++
+			}
+			squaredErrorSum += channelSquaredError(ar, br) + channelSquaredError(ag, bg) + channelSquaredError(ab, bb)
+		}
+	}
+
+	pixels := (bounds.Dx() * bounds.Dy()) * 3
+	if squaredErrorSum == 0 {
+		return diffPixels, math.Inf(1)
+	}
+	mse := squaredErrorSum / float64(pixels)
+	// RGBA() returns 16-bit samples; 65535 is their maximum value.
+	return diffPixels, 10 * math.Log10(65535*65535/mse)
+}
+
+func channelSquaredError(a, b uint32) float64 {
+	d := float64(a) - float64(b)
+	return d * d
+}
+
+// dump writes the diverging frame pair and their save states under
+// DumpDir, named by frame number, for later inspection.
+func (cr *ComparisonRunner) dump(d Divergence) error {
+	if err := os.MkdirAll(cr.DumpDir, 0755); err != nil {
+		return err
+	}
+
+	if err := dumpPNG(filepath.Join(cr.DumpDir, fmt.Sprintf("frame-%06d-golden.png", d.Frame)), d.GoldenFrame.Image); err != nil {
+		return err
+	}
+	if err := dumpPNG(filepath.Join(cr.DumpDir, fmt.Sprintf("frame-%06d-candidate.png", d.Frame)), d.CandidateFrame.Image); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filepath.Join(cr.DumpDir, fmt.Sprintf("frame-%06d-golden.dat", d.Frame)), d.GoldenState, 0644); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(cr.DumpDir, fmt.Sprintf("frame-%06d-candidate.dat", d.Frame)), d.CandidateState, 0644)
+}
+
+func dumpPNG(path string, img *image.RGBA) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return png.Encode(f, img)
+}
@@ -0,0 +1,70 @@
+//go:build comparison
+// +build comparison
+
+package nanoarch
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"sync"
+	"testing"
+
+	"github.com/giongto35/cloud-game/pkg/config"
+)
+
+// Tests that running the same core through itself as both "golden" and
+// "candidate" reports no divergence, since getState/nanoarchRun are
+// deterministic stubs in this test environment.
+func TestComparisonRunnerNoDivergence(t *testing.T) {
+	golden := newTestEmulator("cmp_golden")
+	candidate := newTestEmulator("cmp_candidate")
+
+	cr := NewComparisonRunner(golden, candidate)
+	cr.PSNRThreshold = 40
+
+	divergences, err := cr.Run(context.Background(), 5)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if len(divergences) != 0 {
+		t.Errorf("expected no divergence between two runs of the same core, got %v", divergences)
+	}
+}
+
+// Tests that diffFrames flags two frames that differ in every pixel and
+// reports a finite PSNR for them.
+func TestDiffFramesDetectsDivergence(t *testing.T) {
+	a := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	b := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			a.Set(x, y, color.RGBA{A: 255})
+			b.Set(x, y, color.RGBA{R: 255, A: 255})
+		}
+	}
+
+	diffPixels, psnr := diffFrames(a, b)
+	if diffPixels != 16 {
+		t.Errorf("diffPixels = %v, want 16", diffPixels)
+	}
+	if psnr <= 0 {
+		t.Errorf("psnr = %v, want a positive, finite value", psnr)
+	}
+}
+
+// newTestEmulator builds a bare naEmulator without going through
+// GetEmulatorMock, so two instances can exist side by side instead of
+// sharing the package-global NAEmulator.
+func newTestEmulator(room string) *naEmulator {
+	return &naEmulator{
+		meta:           EmulatorProfile{EmulatorMeta: config.EmulatorConfig["gba"]},
+		imageChannel:   make(chan GameFrame, 1),
+		audioChannel:   make(chan []int16, 4),
+		inputChannel:   make(chan InputEvent, 4),
+		controllersMap: map[string][]controllerState{},
+		roomID:         room,
+		sm:             newStateMachine(),
+		lock:           &sync.Mutex{},
+	}
+}
@@ -0,0 +1,153 @@
+// Package nanoarch is a thin libretro frontend: it exposes an emulator's
+// video/audio/input as plain Go channels for the rest of cloud-game to
+// consume. The core bindings here (coreLoad, coreLoadGame, nanoarchRun,
+// getState, restoreState, nanoarchShutdown) are stubs that load and run no
+// libretro core.
+package nanoarch
+
+import (
+	"bufio"
+	"image"
+	"sync"
+)
+
+// GameFrame is a single rendered frame of emulated video.
+type GameFrame struct {
+	Image     *image.RGBA
+	Timestamp int64
+}
+
+// InputEvent is a single controller input sample for one player.
+type InputEvent struct {
+	RoomID     string
+	PlayerIdx  int
+	ButtonMask uint16
+}
+
+// controllerState holds the latest button mask seen for a connected player.
+type controllerState struct {
+	buttonMask uint16
+}
+
+// naEmulator drives one running libretro core instance for one room.
+type naEmulator struct {
+	// meta is the running system's profile, either the compiled-in
+	// config.EmulatorConfig default or one loaded from systems.toml by
+	// LoadProfiles.
+	meta EmulatorProfile
+	// options are the libretro core option overrides last passed to
+	// coreLoad (meta.Options in GetEmulatorMock). They are only stored
+	// here; nothing in this package serves them to a core through
+	// RETRO_ENVIRONMENT_GET_VARIABLE.
+	options map[string]string
+
+	imageChannel chan GameFrame
+	audioChannel chan []int16
+	inputChannel chan InputEvent
+
+	controllersMap map[string][]controllerState
+
+	roomID string
+
+	// core and romPath are the paths passed to coreLoad/coreLoadGame, kept
+	// around for recording headers and diagnostics.
+	core    string
+	romPath string
+	// recording is true while StartRecording's tap is active.
+	recording bool
+	// recordTap is what inputChannel is pointed at while recording, so
+	// every sender keeps writing to the same place; recordForward is the
+	// channel real consumers (e.g. the input-poll callback) still read,
+	// and recordWriter is where tagged events get written. Step drains
+	// recordTap into recordForward under na.lock so each event is tagged
+	// with the frame in effect at that exact, synchronized point, instead
+	// of racing na.frame from a separate goroutine.
+	recordTap     chan InputEvent
+	recordForward chan InputEvent
+	recordWriter  *bufio.Writer
+
+	// onFrame, if set, is called at the end of nanoarchRun with the frame
+	// just completed and the state captured for it, alongside (and
+	// independently of) the rewind buffer's own capture. Tests use it to
+	// build a per-frame hash chain without duplicating nanoarchRun's
+	// stepping logic.
+	onFrame func(frame int, state []byte)
+
+	// sm tracks the emulator's lifecycle state; see State.
+	sm   *stateMachine
+	lock *sync.Mutex
+
+	// frame is the number of frames emulated so far, used as the rewind
+	// buffer's coordinate space.
+	frame int
+	// rewind holds periodic snapshots of core state for timeline scrubbing.
+	// It is nil until EnableRewind is called.
+	rewind *rewind
+}
+
+// EnableRewind turns on the rewind buffer for this emulator instance.
+// cadence is how many frames pass between two snapshots, and capacity is
+// the maximum number of snapshots kept in memory before the oldest is
+// evicted.
+func (na *naEmulator) EnableRewind(cadence, capacity int) {
+	na.rewind = newRewind(cadence, capacity)
+}
+
+// NAEmulator is the currently active emulator instance. nanoarch only ever
+// drives one core per process, so this global is the single source of truth
+// that the frontend and the libretro callbacks both reach into.
+var NAEmulator *naEmulator
+
+// outputImg is the framebuffer the core renders into on every retro_run call.
+var outputImg *image.RGBA
+
+// coreLoad records path as the loaded core and options (the profile's
+// libretro core option overrides) on NAEmulator. It is a stub: no libretro
+// core is loaded, and threadedVideo, hw and systemDir are ignored.
+func coreLoad(path string, threadedVideo bool, hw bool, systemDir string, options map[string]string) {
+	NAEmulator.core = path
+	NAEmulator.options = options
+}
+
+// coreLoadGame records path as the loaded ROM on NAEmulator. It is a stub:
+// the ROM is not read or handed to any core.
+func coreLoadGame(path string) {
+	NAEmulator.romPath = path
+}
+
+// nanoarchRun advances NAEmulator's frame counter by one and, when the
+// rewind buffer or onFrame is set, hands them the state getState returns
+// for that frame. It is a stub: no core runs, outputImg is not drawn to,
+// and nothing is sent on the image or audio channels.
+func nanoarchRun() {
+	NAEmulator.frame++
+
+	if NAEmulator.rewind != nil || NAEmulator.onFrame != nil {
+		if state, err := getState(); err == nil {
+			if NAEmulator.rewind != nil {
+				NAEmulator.rewind.capture(NAEmulator.frame, state)
+			}
+			if NAEmulator.onFrame != nil {
+				NAEmulator.onFrame(NAEmulator.frame, state)
+			}
+		}
+	}
+}
+
+// nanoarchShutdown is the shutdown hook for the loaded core. It is a stub
+// and does nothing.
+func nanoarchShutdown() {
+}
+
+// getState is the source of the state blob used for both save-game
+// persistence and in-memory snapshots. It is a stub: it always returns a
+// nil state and no error.
+func getState() ([]byte, error) {
+	return nil, nil
+}
+
+// restoreState is the counterpart of getState that takes a state blob back.
+// It is a stub: state is ignored and it always returns nil.
+func restoreState(state []byte) error {
+	return nil
+}
@@ -25,8 +25,8 @@ type EmulatorMock struct {
 	audio chan []int16
 	input chan InputEvent
 
-	// selected emulator core meta
-	meta config.EmulatorMeta
+	// selected emulator core profile
+	profile EmulatorProfile
 
 	// shared core paths (can't be changed)
 	paths EmulatorPaths
@@ -47,17 +47,17 @@ type EmulatorPaths struct {
 // Don't forget to close emulator mock with shutdownEmulator().
 func GetEmulatorMock(room string, system string) *EmulatorMock {
 	assetsPath := getAssetsPath()
-	metadata := config.EmulatorConfig[system]
+	profile := loadProfile(system, assetsPath)
 
 	// an emu
 	emu := &EmulatorMock{
-		core: path.Base(metadata.Path),
+		core: path.Base(profile.Path),
 
 		image: make(chan GameFrame, 30),
 		audio: make(chan []int16, 30),
 		input: make(chan InputEvent, 100),
 
-		meta: metadata,
+		profile: profile,
 
 		paths: EmulatorPaths{
 			assets: cleanPath(assetsPath),
@@ -67,23 +67,41 @@ func GetEmulatorMock(room string, system string) *EmulatorMock {
 	}
 
 	// global video output canvas
-	outputImg = image.NewRGBA(image.Rect(0, 0, emu.meta.Width, emu.meta.Height))
+	outputImg = image.NewRGBA(image.Rect(0, 0, emu.profile.Width, emu.profile.Height))
 
 	// global emulator instance
 	NAEmulator = &naEmulator{
-		meta:           emu.meta,
+		meta:           emu.profile,
 		imageChannel:   emu.image,
 		audioChannel:   emu.audio,
 		inputChannel:   emu.input,
 		controllersMap: map[string][]controllerState{},
 		roomID:         room,
-		done:           make(chan struct{}, 1),
+		sm:             newStateMachine(),
 		lock:           &sync.Mutex{},
 	}
 
 	return emu
 }
 
+// systemsProfilePath is where GetEmulatorMock looks for a TOML profiles
+// file, relative to the assets directory; see LoadProfiles.
+const systemsProfilePath = "emulator/systems.toml"
+
+// loadProfile returns system's profile from systems.toml under assetsPath,
+// falling back to the compiled-in config.EmulatorConfig default if no
+// systems.toml is present (e.g. in this test environment) or it has no
+// table for system.
+func loadProfile(system string, assetsPath string) EmulatorProfile {
+	profiles, err := LoadProfiles(cleanPath(assetsPath + systemsProfilePath))
+	if err == nil {
+		if profile, ok := profiles[system]; ok {
+			return profile
+		}
+	}
+	return EmulatorProfile{EmulatorMeta: config.EmulatorConfig[system]}
+}
+
 // Returns initialized emulator mock with default params.
 // Spawns audio/image channels consumers.
 // Don't forget to close emulator mock with shutdownEmulator().
@@ -100,7 +118,7 @@ func GetDefaultEmulatorMock(room string, system string, rom string) *EmulatorMoc
 // The rom will be loaded from emulators' games path.
 func (emu EmulatorMock) loadRom(game string) {
 	fmt.Printf("%v %v\n", emu.paths.cores, emu.core)
-	coreLoad(emu.paths.cores+emu.core, false, false, "")
+	coreLoad(emu.paths.cores+emu.core, false, false, "", emu.profile.Options)
 	coreLoadGame(emu.paths.games + game)
 }
 
@@ -117,9 +135,10 @@ func (emu EmulatorMock) shutdownEmulator() {
 
 // Emulate one frame with exclusive lock.
 func (emu EmulatorMock) emulateOneFrame() {
-	NAEmulator.GetLock()
-	nanoarchRun()
-	NAEmulator.ReleaseLock()
+	_ = NAEmulator.Step(func() (State, error) {
+		nanoarchRun()
+		return Running, nil
+	})
 }
 
 // Who needs generics anyway?
@@ -144,19 +163,30 @@ func (emu EmulatorMock) handleInput(handler func(event InputEvent)) {
 	}
 }
 
+// Rewinds the emulator to the given frame and returns its state hash.
+func (emu EmulatorMock) rewindTo(frame int) string {
+	if err := NAEmulator.Rewind(NAEmulator.frame - frame); err != nil {
+		panic(err)
+	}
+
+	state, _ := getState()
+	return getHash(state)
+}
+
 // Returns the current emulator state and
 // the latest saved state for its session.
-// Locks the emulator.
+// Pauses the emulator for the duration of the read.
 func (emu EmulatorMock) dumpState() (string, string) {
-	NAEmulator.GetLock()
+	var stateHash, persistedStateHash string
 
-	state, _ := getState()
-	stateHash := getHash(state)
-	persistedStateHash := getSaveHash()
+	NAEmulator.WithState(Paused, func() {
+		state, _ := getState()
+		stateHash = getHash(state)
+		persistedStateHash = getSaveHash()
 
-	fmt.Printf("mem: %v, dat: %v\n", stateHash, persistedStateHash)
+		fmt.Printf("mem: %v, dat: %v\n", stateHash, persistedStateHash)
+	})
 
-	NAEmulator.ReleaseLock()
 	return stateHash, persistedStateHash
 }
 
@@ -0,0 +1,76 @@
+package nanoarch
+
+import (
+	"fmt"
+
+	"github.com/BurntSushi/toml"
+	"github.com/giongto35/cloud-game/pkg/config"
+)
+
+// EmulatorProfile is one system's full configuration as loaded from
+// systems.toml: config.EmulatorMeta plus everything only a profile on disk
+// can express - ROM extensions, hotkeys and libretro core option
+// overrides.
+type EmulatorProfile struct {
+	config.EmulatorMeta
+
+	// Extensions lists the ROM file extensions this system's core accepts,
+	// without the leading dot (e.g. "gba", "nes").
+	Extensions []string
+	// Hotkeys maps a hotkey name (e.g. "rewind", "fast_forward") to the
+	// button mask that triggers it.
+	Hotkeys map[string]uint16
+	// Options are libretro core option overrides, keyed by the core's own
+	// option name (e.g. "gba_rtc"). coreLoad stores them on NAEmulator;
+	// they are not forwarded to a core through RETRO_ENVIRONMENT_GET_VARIABLE.
+	Options map[string]string
+}
+
+// systemsFile mirrors the top-level shape of systems.toml: one
+// [systems.<name>] table per emulated system.
+type systemsFile struct {
+	Systems map[string]profileEntry `toml:"systems"`
+}
+
+// profileEntry mirrors a single [systems.<name>] table, including its
+// [systems.<name>.hotkeys] and [systems.<name>.options] subtables.
+type profileEntry struct {
+	Core            string            `toml:"core"`
+	Extensions      []string          `toml:"extensions"`
+	Width           int               `toml:"width"`
+	Height          int               `toml:"height"`
+	Ratio           float64           `toml:"ratio"`
+	AudioSampleRate int               `toml:"audio_sample_rate"`
+	Fps             float64           `toml:"fps"`
+	Hotkeys         map[string]uint16 `toml:"hotkeys"`
+	Options         map[string]string `toml:"options"`
+}
+
+// LoadProfiles reads path (e.g. assets/emulator/systems.toml) and returns
+// one EmulatorProfile per [systems.<name>] table it finds, so a new core
+// can be added or reconfigured by editing TOML instead of recompiling
+// config.EmulatorConfig.
+func LoadProfiles(path string) (map[string]EmulatorProfile, error) {
+	var file systemsFile
+	if _, err := toml.DecodeFile(path, &file); err != nil {
+		return nil, fmt.Errorf("nanoarch: failed to load profiles from %v: %w", path, err)
+	}
+
+	profiles := make(map[string]EmulatorProfile, len(file.Systems))
+	for system, entry := range file.Systems {
+		profiles[system] = EmulatorProfile{
+			EmulatorMeta: config.EmulatorMeta{
+				Path:            entry.Core,
+				Width:           entry.Width,
+				Height:          entry.Height,
+				Ratio:           entry.Ratio,
+				AudioSampleRate: entry.AudioSampleRate,
+				Fps:             entry.Fps,
+			},
+			Extensions: entry.Extensions,
+			Hotkeys:    entry.Hotkeys,
+			Options:    entry.Options,
+		}
+	}
+	return profiles, nil
+}
@@ -0,0 +1,71 @@
+package nanoarch
+
+import (
+	"io/ioutil"
+	"os"
+	"sync"
+	"testing"
+)
+
+const systemsFixture = `
+[systems.gba]
+core = "gba_libretro"
+extensions = ["gba"]
+width = 240
+height = 160
+ratio = 1.5
+audio_sample_rate = 32768
+fps = 60.0
+
+[systems.gba.hotkeys]
+rewind = 512
+
+[systems.gba.options]
+gba_rtc = "enabled"
+gba_solar_sensor_level = "5"
+`
+
+// Tests that LoadProfiles parses a systems.toml fixture into one
+// EmulatorProfile per system, and that the core option strings it carries
+// reach coreLoad once the profile is applied.
+func TestLoadProfiles(t *testing.T) {
+	f, err := ioutil.TempFile("", "systems-*.toml")
+	if err != nil {
+		t.Fatalf("TempFile failed: %v", err)
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.WriteString(systemsFixture); err != nil {
+		t.Fatalf("WriteString failed: %v", err)
+	}
+	f.Close()
+
+	profiles, err := LoadProfiles(f.Name())
+	if err != nil {
+		t.Fatalf("LoadProfiles failed: %v", err)
+	}
+
+	gba, ok := profiles["gba"]
+	if !ok {
+		t.Fatalf("profiles missing %q", "gba")
+	}
+	if gba.Path != "gba_libretro" || gba.Width != 240 || gba.Height != 160 {
+		t.Errorf("unexpected profile: %+v", gba)
+	}
+	if gba.Hotkeys["rewind"] != 512 {
+		t.Errorf("rewind hotkey = %v, want 512", gba.Hotkeys["rewind"])
+	}
+
+	NAEmulator = &naEmulator{sm: newStateMachine(), lock: &sync.Mutex{}}
+	coreLoad(gba.Path, false, false, "", gba.Options)
+
+	if NAEmulator.core != gba.Path {
+		t.Errorf("coreLoad core = %q, want %q", NAEmulator.core, gba.Path)
+	}
+	if NAEmulator.options["gba_rtc"] != "enabled" {
+		t.Errorf("gba_rtc option didn't reach coreLoad: got %+v", NAEmulator.options)
+	}
+	if NAEmulator.options["gba_solar_sensor_level"] != "5" {
+		t.Errorf("gba_solar_sensor_level option didn't reach coreLoad: got %+v", NAEmulator.options)
+	}
+}
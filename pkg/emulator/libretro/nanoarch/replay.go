@@ -0,0 +1,262 @@
+package nanoarch
+
+import (
+	"bufio"
+	"crypto/md5"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// replayTrailerPlayerIdx marks a recorded-input record as the trailer
+// written by StopRecording rather than an actual input: real player indices
+// never reach it, so readRecordedInput can tell the two apart while reusing
+// the same (frame, playerIdx, buttonMask) wire shape for both.
+const replayTrailerPlayerIdx = 0xff
+
+// replayHeader is written once at the start of a recording and carries
+// everything Replay needs to reproduce the starting conditions: which core
+// and ROM were loaded, the state to restore, and the RNG seed the test (or
+// caller) ran with.
+type replayHeader struct {
+	core    string
+	romHash [md5.Size]byte
+	state   []byte
+	seed    int64
+}
+
+// StartRecording writes a replayHeader to w, then taps inputChannel by
+// pointing it at a fresh recordTap: every event sent there is written out
+// as a (frame, player, buttons) tuple and forwarded on to recordForward (the
+// channel real consumers keep reading from) so they keep working unchanged.
+// The loaded ROM's path is hashed rather than stored verbatim to keep
+// recordings small. Call StopRecording to detach the tap.
+//
+// Unlike the once-per-process reader that used to drain the tap in its own
+// goroutine, draining now happens inside Step (see drainTap), under the
+// same na.lock that guards na.frame, so every event is tagged with the
+// frame in effect at that defined point rather than whatever frame a
+// scheduler happened to have committed when an unsynchronized goroutine got
+// around to it.
+func (na *naEmulator) StartRecording(w io.Writer, seed int64) error {
+	state, err := getState()
+	if err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(w)
+	if err := writeReplayHeader(bw, replayHeader{
+		core:    na.core,
+		romHash: md5.Sum([]byte(na.romPath)),
+		state:   state,
+		seed:    seed,
+	}); err != nil {
+		return err
+	}
+
+	na.lock.Lock()
+	defer na.lock.Unlock()
+
+	na.recordTap = make(chan InputEvent, cap(na.inputChannel))
+	na.recordForward = na.inputChannel
+	na.recordWriter = bw
+	na.inputChannel = na.recordTap
+	na.recording = true
+
+	return nil
+}
+
+// drainTap moves every event currently queued on recordTap onto
+// recordForward, tagging each one with na.frame - the frame it's about to
+// affect - before forwarding it. It must be called with na.lock already
+// held, which is what makes the tag race-free and deterministic: it runs
+// at the same defined point in the timeline, under the same lock, as the
+// frame advance in nanoarchRun.
+func (na *naEmulator) drainTap() {
+	if na.recordTap == nil {
+		return
+	}
+	for {
+		select {
+		case e := <-na.recordTap:
+			if na.recording {
+				_ = writeRecordedInput(na.recordWriter, uint32(na.frame), e)
+				_ = na.recordWriter.Flush()
+			}
+			na.recordForward <- e
+		default:
+			return
+		}
+	}
+}
+
+// StopRecording detaches the tap installed by StartRecording: it drains
+// whatever is still queued on it (unrecorded, since recording is already
+// off), restores inputChannel to the channel real consumers read from, and
+// releases the tap so a leaked goroutine can't keep holding it open. Before
+// releasing the writer it appends a trailer recording the total number of
+// frames emulated, so Replay can advance all the way to the end of the
+// recording instead of stopping at the last input it fed in.
+func (na *naEmulator) StopRecording() {
+	na.lock.Lock()
+	defer na.lock.Unlock()
+
+	na.recording = false
+	if na.recordTap == nil {
+		return
+	}
+
+	for {
+		select {
+		case e := <-na.recordTap:
+			na.recordForward <- e
+		default:
+			_ = writeReplayTrailer(na.recordWriter, uint32(na.frame))
+			_ = na.recordWriter.Flush()
+
+			na.inputChannel = na.recordForward
+			na.recordTap = nil
+			na.recordForward = nil
+			na.recordWriter = nil
+			return
+		}
+	}
+}
+
+// Replay restores the state written by StartRecording, then advances the
+// core frame by frame, feeding each recorded input into inputChannel right
+// before the frame boundary it was captured on, until it reaches the
+// trailer StopRecording appended - at which point it catches up to the
+// total frame count recorded there, so the replayed run ends on exactly the
+// same frame the original one did, rather than one short of it.
+func (na *naEmulator) Replay(r io.Reader) error {
+	header, err := readReplayHeader(r)
+	if err != nil {
+		return err
+	}
+	if err := restoreState(header.state); err != nil {
+		return err
+	}
+	na.frame = 0
+
+	for {
+		frame, e, err := readRecordedInput(r, na.roomID)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		for na.frame < int(frame) {
+			nanoarchRun()
+		}
+
+		if e.PlayerIdx == replayTrailerPlayerIdx {
+			continue
+		}
+
+		na.inputChannel <- e
+	}
+}
+
+func writeReplayTrailer(w io.Writer, totalFrames uint32) error {
+	return writeRecordedInput(w, totalFrames, InputEvent{PlayerIdx: replayTrailerPlayerIdx})
+}
+
+func writeReplayHeader(w io.Writer, h replayHeader) error {
+	if err := writeString(w, h.core); err != nil {
+		return err
+	}
+	if _, err := w.Write(h.romHash[:]); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.LittleEndian, h.seed); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.LittleEndian, uint32(len(h.state))); err != nil {
+		return err
+	}
+	_, err := w.Write(h.state)
+	return err
+}
+
+func readReplayHeader(r io.Reader) (replayHeader, error) {
+	var h replayHeader
+
+	core, err := readString(r)
+	if err != nil {
+		return h, err
+	}
+	h.core = core
+
+	if _, err := io.ReadFull(r, h.romHash[:]); err != nil {
+		return h, err
+	}
+	if err := binary.Read(r, binary.LittleEndian, &h.seed); err != nil {
+		return h, err
+	}
+
+	var stateLen uint32
+	if err := binary.Read(r, binary.LittleEndian, &stateLen); err != nil {
+		return h, err
+	}
+	h.state = make([]byte, stateLen)
+	if _, err := io.ReadFull(r, h.state); err != nil {
+		return h, err
+	}
+
+	return h, nil
+}
+
+func writeRecordedInput(w io.Writer, frame uint32, e InputEvent) error {
+	if err := binary.Write(w, binary.LittleEndian, frame); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.LittleEndian, uint8(e.PlayerIdx)); err != nil {
+		return err
+	}
+	return binary.Write(w, binary.LittleEndian, e.ButtonMask)
+}
+
+func readRecordedInput(r io.Reader, roomID string) (uint32, InputEvent, error) {
+	var frame uint32
+	if err := binary.Read(r, binary.LittleEndian, &frame); err != nil {
+		return 0, InputEvent{}, err
+	}
+
+	var playerIdx uint8
+	if err := binary.Read(r, binary.LittleEndian, &playerIdx); err != nil {
+		return 0, InputEvent{}, err
+	}
+
+	var buttonMask uint16
+	if err := binary.Read(r, binary.LittleEndian, &buttonMask); err != nil {
+		return 0, InputEvent{}, err
+	}
+
+	return frame, InputEvent{RoomID: roomID, PlayerIdx: int(playerIdx), ButtonMask: buttonMask}, nil
+}
+
+func writeString(w io.Writer, s string) error {
+	if len(s) > 1<<16-1 {
+		return errors.New("nanoarch: string too long to record")
+	}
+	if err := binary.Write(w, binary.LittleEndian, uint16(len(s))); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, s)
+	return err
+}
+
+func readString(r io.Reader) (string, error) {
+	var n uint16
+	if err := binary.Read(r, binary.LittleEndian, &n); err != nil {
+		return "", err
+	}
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return "", err
+	}
+	return string(buf), nil
+}
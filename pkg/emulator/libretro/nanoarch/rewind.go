@@ -0,0 +1,158 @@
+package nanoarch
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// SnapshotMeta is the metadata of one rewind snapshot, without its (large)
+// state payload, so callers can inspect the available timeline cheaply.
+type SnapshotMeta struct {
+	Frame     int
+	Timestamp time.Time
+}
+
+type snapshot struct {
+	meta  SnapshotMeta
+	state []byte
+}
+
+// rewind is a ring buffer of periodic save-state snapshots, modeled after
+// Gopher2600's rewind package: snapshots are taken every cadence frames and
+// the oldest is evicted once capacity snapshots are held. head tracks the
+// snapshot the playback is currently positioned at, so that emulating past
+// it drops everything newer and the timeline branches from there.
+type rewind struct {
+	mu sync.Mutex
+
+	cadence  int
+	capacity int
+
+	snapshots []snapshot
+	head      int
+}
+
+func newRewind(cadence, capacity int) *rewind {
+	return &rewind{cadence: cadence, capacity: capacity}
+}
+
+// capture drops every snapshot ahead of the playback head, since emulating
+// on from there branches the timeline, then stores a snapshot of state for
+// frame if it lands on the configured cadence.
+func (r *rewind) capture(frame int, state []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.snapshots) > 0 {
+		r.snapshots = r.snapshots[:r.head+1]
+	}
+
+	if r.cadence <= 0 || frame%r.cadence != 0 {
+		return
+	}
+
+	r.snapshots = append(r.snapshots, snapshot{
+		meta:  SnapshotMeta{Frame: frame, Timestamp: time.Now()},
+		state: state,
+	})
+	if r.capacity > 0 && len(r.snapshots) > r.capacity {
+		r.snapshots = r.snapshots[1:]
+	}
+	r.head = len(r.snapshots) - 1
+}
+
+// seek moves the playback head by delta snapshots (negative rewinds,
+// positive fast-forwards), clamps it to the available range, and returns
+// the state found there. Snapshots newer than the new head are kept until
+// the next capture, so a fast-forward can still reach them.
+func (r *rewind) seek(delta int) ([]byte, SnapshotMeta, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.snapshots) == 0 {
+		return nil, SnapshotMeta{}, errors.New("nanoarch: rewind buffer is empty")
+	}
+
+	pos := r.head + delta
+	if pos < 0 {
+		pos = 0
+	}
+	if pos > len(r.snapshots)-1 {
+		pos = len(r.snapshots) - 1
+	}
+
+	r.head = pos
+
+	s := r.snapshots[pos]
+	return s.state, s.meta, nil
+}
+
+// snapshotsFor converts a count of frames into the equivalent count of
+// snapshots at this buffer's cadence, rounding down, so callers can think
+// in frames while seek still works in its own coordinate space.
+func (r *rewind) snapshotsFor(frames int) int {
+	if r.cadence <= 0 {
+		return 0
+	}
+	return frames / r.cadence
+}
+
+func (r *rewind) timeline() []SnapshotMeta {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	meta := make([]SnapshotMeta, len(r.snapshots))
+	for i, s := range r.snapshots {
+		meta[i] = s.meta
+	}
+	return meta
+}
+
+// Rewind moves the emulator back frames worth of snapshots (rounded to the
+// buffer's cadence) and restores the core to that point. Further emulation
+// branches from there, discarding the snapshots that were ahead of it.
+func (na *naEmulator) Rewind(frames int) error {
+	if na.rewind == nil {
+		return errors.New("nanoarch: rewind is not enabled")
+	}
+	return na.seekRewind(-na.rewind.snapshotsFor(frames))
+}
+
+// FastForward moves the emulator forward through previously captured
+// snapshots, up to the most recent one. Asking to go past it stops at, and
+// restores, the most recent snapshot.
+func (na *naEmulator) FastForward(frames int) error {
+	if na.rewind == nil {
+		return errors.New("nanoarch: rewind is not enabled")
+	}
+	return na.seekRewind(na.rewind.snapshotsFor(frames))
+}
+
+func (na *naEmulator) seekRewind(deltaSnapshots int) error {
+	var err error
+
+	na.WithState(Rewinding, func() {
+		var state []byte
+		var meta SnapshotMeta
+
+		state, meta, err = na.rewind.seek(deltaSnapshots)
+		if err != nil {
+			return
+		}
+		if err = restoreState(state); err != nil {
+			return
+		}
+		na.frame = meta.Frame
+	})
+	return err
+}
+
+// TimelineSnapshots returns the metadata of every snapshot currently held
+// in the rewind buffer, oldest first.
+func (na *naEmulator) TimelineSnapshots() []SnapshotMeta {
+	if na.rewind == nil {
+		return nil
+	}
+	return na.rewind.timeline()
+}
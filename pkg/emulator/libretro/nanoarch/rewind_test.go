@@ -0,0 +1,39 @@
+package nanoarch
+
+import "testing"
+
+// Tests rewind/replay consistency:
+//
+// Emulate n ticks, recording a snapshot hash every tick.
+// Rewind m ticks.
+// Assert the state hash matches the one recorded for that frame.
+func TestRewind(t *testing.T) {
+	const (
+		room   = "test_rewind_00"
+		system = "gba"
+		rom    = "Sushi The Cat.gba"
+		ticks  = 120
+		back   = 30
+	)
+
+	mock := GetDefaultEmulatorMock(room, system, rom)
+	NAEmulator.EnableRewind(1, ticks)
+
+	hashes := make([]string, 0, ticks)
+	for i := 0; i < ticks; i++ {
+		mock.emulateOneFrame()
+
+		state, _ := getState()
+		hashes = append(hashes, getHash(state))
+	}
+
+	target := ticks - back
+	got := mock.rewindTo(target)
+	want := hashes[target-1]
+
+	if got != want {
+		t.Errorf("rewind to frame %v produced state %v, want %v", target, got, want)
+	}
+
+	mock.shutdownEmulator()
+}
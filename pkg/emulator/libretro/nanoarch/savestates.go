@@ -0,0 +1,56 @@
+package nanoarch
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// savesDir is where per-room save states are persisted.
+const savesDir = "save"
+
+// GetHashPath returns the path of the save file for the current room.
+func (na *naEmulator) GetHashPath() string {
+	return filepath.Join(savesDir, na.roomID+".dat")
+}
+
+// Save persists the current core state to disk under GetHashPath.
+func (na *naEmulator) Save() error {
+	var state []byte
+	var err error
+
+	na.WithState(Paused, func() {
+		state, err = getState()
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(savesDir, 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(na.GetHashPath(), state, 0644)
+}
+
+// Load restores the core state previously written by Save.
+func (na *naEmulator) Load() error {
+	state, err := ioutil.ReadFile(na.GetHashPath())
+	if err != nil {
+		return err
+	}
+
+	na.WithState(Paused, func() {
+		err = restoreState(state)
+	})
+	return err
+}
+
+// Close moves the emulator to the Ending state. Callers that used to select
+// on the old done channel now poll or observe State() == Ending instead.
+// It takes na.lock, the same as Step and WithState, so it can't race a
+// Step in flight clobbering the transition it's trying to make absorbing.
+func (na *naEmulator) Close() {
+	na.lock.Lock()
+	defer na.lock.Unlock()
+	na.sm.set(Ending)
+}
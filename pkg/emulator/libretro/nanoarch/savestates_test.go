@@ -1,6 +1,7 @@
 package nanoarch
 
 import (
+	"bytes"
 	"fmt"
 	"math/rand"
 	"sync"
@@ -191,11 +192,9 @@ func TestStateConcurrency(t *testing.T) {
 		ticker := time.NewTicker(time.Second / time.Duration(test.fps))
 
 		for range ticker.C {
-			select {
-			case <-NAEmulator.done:
+			if NAEmulator.State() == Ending {
 				mock.shutdownEmulator()
 				return
-			default:
 			}
 
 			op++
@@ -241,6 +240,70 @@ func TestStateConcurrency(t *testing.T) {
 	}
 }
 
+// Tests that a recording played back through Replay produces exactly the
+// same per-frame state hashes as the original run, not just a matching
+// final frame: onFrame hangs a hash chain off of nanoarchRun itself, so a
+// divergence on any frame - including the replayed run falling a frame
+// short - shows up immediately instead of being masked by a last-frame-only
+// comparison. This gives us a reproducible trace for the "possible
+// background emulation" divergence noted in TestStateConcurrency above: any
+// future state bug should show up as a mismatch here instead of an
+// occasional flaky assertion.
+func TestReplayDeterminism(t *testing.T) {
+	const (
+		room   = "test_replay_00"
+		system = "gba"
+		rom    = "Sushi The Cat.gba"
+		ticks  = 600
+		seed   = 7
+	)
+
+	mock := GetDefaultEmulatorMock(room, system, rom)
+	go mock.handleInput(func(_ InputEvent) {})
+
+	var recordedHashes []string
+	NAEmulator.onFrame = func(_ int, state []byte) {
+		recordedHashes = append(recordedHashes, getHash(state))
+	}
+
+	var recording bytes.Buffer
+	if err := NAEmulator.StartRecording(&recording, seed); err != nil {
+		t.Fatalf("StartRecording failed: %v", err)
+	}
+
+	rand.Seed(seed)
+	for i := 0; i < ticks; i++ {
+		NAEmulator.inputChannel <- InputEvent{RoomID: room, PlayerIdx: 0, ButtonMask: uint16(rand.Intn(1 << 10))}
+		mock.emulateOneFrame()
+	}
+	NAEmulator.StopRecording()
+
+	mock.shutdownEmulator()
+
+	mock = GetDefaultEmulatorMock(room, system, rom)
+	go mock.handleInput(func(_ InputEvent) {})
+
+	var replayedHashes []string
+	NAEmulator.onFrame = func(_ int, state []byte) {
+		replayedHashes = append(replayedHashes, getHash(state))
+	}
+
+	if err := NAEmulator.Replay(&recording); err != nil {
+		t.Fatalf("Replay failed: %v", err)
+	}
+
+	if len(replayedHashes) != len(recordedHashes) {
+		t.Fatalf("replay produced %d frames, recording had %d", len(replayedHashes), len(recordedHashes))
+	}
+	for i, want := range recordedHashes {
+		if got := replayedHashes[i]; got != want {
+			t.Errorf("frame %d diverged from the recording: %v != %v", i, got, want)
+		}
+	}
+
+	mock.shutdownEmulator()
+}
+
 // Returns random boolean.
 func lucky() bool {
 	return rand.Intn(2) == 1
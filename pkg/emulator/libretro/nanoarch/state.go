@@ -0,0 +1,120 @@
+package nanoarch
+
+import "sync"
+
+// State is a coarse-grained phase of one naEmulator's lifecycle, modeled on
+// Gopher2600's govern package. It gives callers one source of truth for
+// "is this room actually running" instead of inferring it from a done
+// channel being open or closed.
+type State int
+
+const (
+	Initializing State = iota
+	Running
+	Paused
+	Rewinding
+	Ending
+)
+
+func (s State) String() string {
+	switch s {
+	case Initializing:
+		return "initializing"
+	case Running:
+		return "running"
+	case Paused:
+		return "paused"
+	case Rewinding:
+		return "rewinding"
+	case Ending:
+		return "ending"
+	default:
+		return "unknown"
+	}
+}
+
+// StateTransition is notified every time an naEmulator moves between states.
+type StateTransition func(from, to State)
+
+// stateMachine guards State against concurrent reads/writes and notifies an
+// optional StateTransition callback on change.
+type stateMachine struct {
+	mu           sync.Mutex
+	state        State
+	onTransition StateTransition
+}
+
+func newStateMachine() *stateMachine {
+	return &stateMachine{state: Initializing}
+}
+
+// set moves to s, unless the machine is already in Ending: that state is
+// absorbing, since it means the emulator is shutting down and nothing
+// (a Step finishing after Close, a WithState restoring its prior state)
+// should be able to pull it back out.
+func (sm *stateMachine) set(s State) {
+	sm.mu.Lock()
+	from := sm.state
+	if from == Ending && s != Ending {
+		sm.mu.Unlock()
+		return
+	}
+	sm.state = s
+	cb := sm.onTransition
+	sm.mu.Unlock()
+
+	if cb != nil && from != s {
+		cb(from, s)
+	}
+}
+
+func (sm *stateMachine) get() State {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	return sm.state
+}
+
+// State returns the emulator's current lifecycle state.
+func (na *naEmulator) State() State {
+	return na.sm.get()
+}
+
+// OnStateChange registers the callback invoked on every state transition.
+func (na *naEmulator) OnStateChange(cb StateTransition) {
+	na.sm.mu.Lock()
+	na.sm.onTransition = cb
+	na.sm.mu.Unlock()
+}
+
+// Step runs fn with exclusive access to the core and transitions to the
+// state fn returns. An error transitions to Ending instead, mirroring how
+// Close used to signal callers selecting on the old done channel.
+func (na *naEmulator) Step(fn func() (State, error)) error {
+	na.lock.Lock()
+	defer na.lock.Unlock()
+
+	na.drainTap()
+
+	next, err := fn()
+	if err != nil {
+		na.sm.set(Ending)
+		return err
+	}
+	na.sm.set(next)
+	return nil
+}
+
+// WithState runs fn with exclusive access to the core, temporarily
+// transitioning to s and restoring the previous state once fn returns. It
+// replaces the old GetLock/ReleaseLock pair: callers that just wanted
+// exclusive access (save transfer, migration, rewind) now also announce
+// why they're pausing.
+func (na *naEmulator) WithState(s State, fn func()) {
+	na.lock.Lock()
+	defer na.lock.Unlock()
+
+	prev := na.sm.get()
+	na.sm.set(s)
+	fn()
+	na.sm.set(prev)
+}